@@ -0,0 +1,128 @@
+// Command etmp-kms-init bootstraps a validator key on a KMS/HSM backend,
+// following the pattern of smallstep/certificates' cmd/step-awskms-init.
+//
+// It creates a new secp256k1 signing key on the chosen backend, derives
+// and prints the resulting Ethereum address, and emits a ready-to-use
+// secretsManagerConfig JSON blob that operators can drop straight into
+// their node config, replacing the previous manual setup where the
+// configured key id was never actually validated against the backend.
+//
+// The gcpkms backend does not create keys: its CreateKey always returns an
+// error, so etmp-kms-init exits with that error for --backend=gcpkms.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/0xPolygon/polygon-edge/secrets/kms"
+	_ "github.com/0xPolygon/polygon-edge/secrets/kms/awskms"
+	_ "github.com/0xPolygon/polygon-edge/secrets/kms/gcpkms"
+	_ "github.com/0xPolygon/polygon-edge/secrets/kms/pkcs11"
+)
+
+// secretsManagerConfig mirrors the shape of secrets.SecretsManagerConfig
+// so the printed blob can be pasted directly into a node's config file
+type secretsManagerConfig struct {
+	Type  string            `json:"type"`
+	Name  string            `json:"name"`
+	Extra map[string]string `json:"extra"`
+}
+
+// genesisValidator is the minimal entry etmp-kms-init can contribute to a
+// genesis validator set; anything chain-specific beyond the address is
+// left for the operator to fill in
+type genesisValidator struct {
+	Address string `json:"address"`
+}
+
+func main() {
+	// kmip is deliberately not listed here: it speaks KMIP directly
+	// (see secrets/kmip) rather than implementing kms.KMS, so it isn't
+	// registered with this package and can't be selected through Options.Type
+	backend := flag.String("backend", "awskms", "KMS backend to provision the key on (awskms|gcpkms|pkcs11); gcpkms does not support key creation")
+	nodeName := flag.String("node-name", "", "node name to embed in the emitted secretsManagerConfig (required)")
+	keyName := flag.String("key-name", "", "hint/alias used when creating the key on the backend")
+	region := flag.String("region", "", "backend region, required for awskms")
+	endpoint := flag.String("endpoint", "", "backend service endpoint override")
+	credentialsFile := flag.String("credentials-file", "", "path to a service account/credentials file, used by gcpkms")
+	modulePath := flag.String("module-path", "", "path to the PKCS#11 shared library, required for pkcs11")
+	tokenLabel := flag.String("token-label", "", "PKCS#11 token label")
+	pin := flag.String("pin", "", "PKCS#11 user PIN")
+	emitGenesis := flag.Bool("genesis", false, "also emit a genesis validator entry for the new key")
+
+	flag.Parse()
+
+	if *nodeName == "" {
+		fatalf("--node-name is required")
+	}
+
+	opts := kms.Options{
+		Type:            kms.Type(*backend),
+		Region:          *region,
+		Endpoint:        *endpoint,
+		CredentialsFile: *credentialsFile,
+		ModulePath:      *modulePath,
+		TokenLabel:      *tokenLabel,
+		Pin:             *pin,
+	}
+
+	ctx := context.Background()
+
+	backendKMS, err := kms.New(ctx, opts)
+	if err != nil {
+		fatalf("unable to initialize %s backend: %v", *backend, err)
+	}
+	defer backendKMS.Close()
+
+	created, err := backendKMS.CreateKey(ctx, &kms.CreateKeyRequest{Name: *keyName})
+	if err != nil {
+		fatalf("unable to create key: %v", err)
+	}
+
+	info, err := kms.PublicKeyDERToSecretInfo(created.PublicKeyDER)
+	if err != nil {
+		fatalf("unable to derive address from new key: %v", err)
+	}
+
+	fmt.Printf("key id:  %s\n", created.KeyID)
+	fmt.Printf("pubkey:  %s\n", info.Pubkey)
+	fmt.Printf("address: %s\n\n", info.Address)
+
+	cfg := secretsManagerConfig{
+		Type: *backend,
+		Name: *nodeName,
+		Extra: map[string]string{
+			"kms-key-id": created.KeyID,
+			"region":     *region,
+			"endpoint":   *endpoint,
+
+			"credentials-file": *credentialsFile,
+			"module-path":      *modulePath,
+			"token-label":      *tokenLabel,
+		},
+	}
+
+	printJSON("secretsManagerConfig", &cfg)
+
+	if *emitGenesis {
+		printJSON("genesis validator", &genesisValidator{Address: info.Address})
+	}
+}
+
+func printJSON(label string, v interface{}) {
+	bs, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		fatalf("unable to marshal %s: %v", label, err)
+	}
+
+	fmt.Printf("%s:\n%s\n\n", label, bs)
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "etmp-kms-init: "+format+"\n", args...)
+	os.Exit(1)
+}
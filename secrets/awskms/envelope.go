@@ -0,0 +1,224 @@
+package awskms
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+)
+
+// envelopeFormatVersion is bumped whenever the on-disk envelope layout
+// changes. UnwrapDataKey accepts every version up to this one, so
+// RotateMasterKey can move existing secrets onto a new master key version
+// without breaking decryption of anything it hasn't re-wrapped yet
+const envelopeFormatVersion uint32 = 1
+
+// envelope is the self-describing on-disk representation of an
+// envelope-encrypted secret, following the master-key/DEK split MinKMS
+// uses: a KMS-wrapped AES-256 data key, plus the AES-256-GCM ciphertext of
+// the secret itself
+type envelope struct {
+	Version    uint32 `json:"version"`
+	KmsKeyArn  string `json:"kms_key_arn"`
+	WrappedDEK []byte `json:"wrapped_dek"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// WrapDataKey generates a fresh AES-256 data key under masterKeyID and
+// uses it to AES-256-GCM encrypt plaintext, returning the self-describing
+// envelope to store on disk
+func (k *KmsSecretManager) WrapDataKey(plaintext []byte) ([]byte, error) {
+	if k.envelopeClient == nil {
+		return nil, errors.New("aws kms: envelope encryption is not configured, set master-key-id")
+	}
+
+	out, err := k.envelopeClient.GenerateDataKey(context.Background(), &kms.GenerateDataKeyInput{
+		KeyId:   awssdk.String(k.masterKeyID),
+		KeySpec: types.DataKeySpecAes256,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("kms generate data key error: %w", err)
+	}
+
+	nonce, ciphertext, err := sealWithDEK(out.Plaintext, plaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	env := &envelope{
+		Version:    envelopeFormatVersion,
+		KmsKeyArn:  awssdk.ToString(out.KeyId),
+		WrappedDEK: out.CiphertextBlob,
+		Nonce:      nonce,
+		Ciphertext: ciphertext,
+	}
+
+	return json.Marshal(env)
+}
+
+// UnwrapDataKey decrypts an envelope produced by WrapDataKey. It accepts
+// any envelope format version up to envelopeFormatVersion, so secrets
+// written under a previous master key version still decrypt correctly
+// between RotateMasterKey runs
+func (k *KmsSecretManager) UnwrapDataKey(data []byte) ([]byte, error) {
+	if k.envelopeClient == nil {
+		return nil, errors.New("aws kms: envelope encryption is not configured, set master-key-id")
+	}
+
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("unable to parse envelope: %w", err)
+	}
+
+	if err := validateEnvelopeVersion(env.Version); err != nil {
+		return nil, err
+	}
+
+	out, err := k.envelopeClient.Decrypt(context.Background(), &kms.DecryptInput{
+		CiphertextBlob: env.WrappedDEK,
+		KeyId:          awssdk.String(env.KmsKeyArn),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("kms decrypt error: %w", err)
+	}
+
+	return openWithDEK(out.Plaintext, env.Nonce, env.Ciphertext)
+}
+
+// validateEnvelopeVersion rejects an envelope written by a format this
+// build doesn't understand. Every version up to envelopeFormatVersion is
+// accepted, so secrets written under a previous master key version still
+// decrypt correctly between RotateMasterKey runs
+func validateEnvelopeVersion(version uint32) error {
+	if version == 0 || version > envelopeFormatVersion {
+		return fmt.Errorf("unsupported envelope format version %d", version)
+	}
+
+	return nil
+}
+
+// sealWithDEK AES-256-GCM encrypts plaintext under dek, returning the
+// randomly generated nonce and the resulting ciphertext. Kept free of any
+// KMS calls so it can be exercised by a unit test in isolation
+func sealWithDEK(dek, plaintext []byte) (nonce, ciphertext []byte, err error) {
+	gcm, err := newGCM(dek)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, fmt.Errorf("unable to generate nonce: %w", err)
+	}
+
+	return nonce, gcm.Seal(nil, nonce, plaintext, nil), nil
+}
+
+// openWithDEK is the inverse of sealWithDEK
+func openWithDEK(dek, nonce, ciphertext []byte) ([]byte, error) {
+	gcm, err := newGCM(dek)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt envelope: %w", err)
+	}
+
+	return plaintext, nil
+}
+
+// RotateMasterKey moves every envelope at the given paths under the current
+// masterKeyID: each envelope is decrypted, then wrapped again from scratch
+// with a freshly generated DEK. The old DEK is not re-wrapped, and the
+// plaintext of each secret is held in memory while it is re-encrypted
+func (k *KmsSecretManager) RotateMasterKey(paths []string) error {
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("unable to read %s: %w", path, err)
+		}
+
+		plaintext, err := k.UnwrapDataKey(data)
+		if err != nil {
+			return fmt.Errorf("unable to unwrap %s: %w", path, err)
+		}
+
+		rewrapped, err := k.WrapDataKey(plaintext)
+		if err != nil {
+			return fmt.Errorf("unable to rewrap %s: %w", path, err)
+		}
+
+		if err := writeFileAtomic(path, rewrapped); err != nil {
+			return fmt.Errorf("unable to write %s: %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+// writeFileAtomic writes data to a temp file in the same directory as path
+// and renames it over path, so a crash or power loss mid-rotation can only
+// ever leave the pre-rotation envelope in place instead of a truncated or
+// partially-written one
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("unable to create temp file: %w", err)
+	}
+
+	tmpPath := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+
+		return fmt.Errorf("unable to write temp file: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+
+		return fmt.Errorf("unable to close temp file: %w", err)
+	}
+
+	if err := os.Chmod(tmpPath, 0o600); err != nil {
+		os.Remove(tmpPath)
+
+		return fmt.Errorf("unable to set temp file permissions: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+
+		return fmt.Errorf("unable to rename temp file into place: %w", err)
+	}
+
+	return nil
+}
+
+// newGCM builds an AES-256-GCM AEAD from a raw data key
+func newGCM(dek []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(dek)
+	if err != nil {
+		return nil, fmt.Errorf("unable to build aes cipher: %w", err)
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("unable to build gcm: %w", err)
+	}
+
+	return gcm, nil
+}
@@ -0,0 +1,116 @@
+package awskms
+
+import (
+	"bytes"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSealOpenWithDEKRoundTrip(t *testing.T) {
+	dek := make([]byte, 32)
+	if _, err := rand.Read(dek); err != nil {
+		t.Fatalf("unable to generate dek: %v", err)
+	}
+
+	plaintext := []byte("super secret network key")
+
+	nonce, ciphertext, err := sealWithDEK(dek, plaintext)
+	if err != nil {
+		t.Fatalf("sealWithDEK returned error: %v", err)
+	}
+
+	got, err := openWithDEK(dek, nonce, ciphertext)
+	if err != nil {
+		t.Fatalf("openWithDEK returned error: %v", err)
+	}
+
+	if !bytes.Equal(got, plaintext) {
+		t.Fatalf("round trip mismatch: got %q want %q", got, plaintext)
+	}
+}
+
+func TestOpenWithDEKRejectsTamperedCiphertext(t *testing.T) {
+	dek := make([]byte, 32)
+	if _, err := rand.Read(dek); err != nil {
+		t.Fatalf("unable to generate dek: %v", err)
+	}
+
+	nonce, ciphertext, err := sealWithDEK(dek, []byte("validator network key"))
+	if err != nil {
+		t.Fatalf("sealWithDEK returned error: %v", err)
+	}
+
+	ciphertext[0] ^= 0xFF
+
+	if _, err := openWithDEK(dek, nonce, ciphertext); err == nil {
+		t.Fatal("expected openWithDEK to reject a tampered ciphertext")
+	}
+}
+
+func TestOpenWithDEKRejectsWrongDEK(t *testing.T) {
+	dek := make([]byte, 32)
+	if _, err := rand.Read(dek); err != nil {
+		t.Fatalf("unable to generate dek: %v", err)
+	}
+
+	nonce, ciphertext, err := sealWithDEK(dek, []byte("validator network key"))
+	if err != nil {
+		t.Fatalf("sealWithDEK returned error: %v", err)
+	}
+
+	wrongDEK := make([]byte, 32)
+	if _, err := rand.Read(wrongDEK); err != nil {
+		t.Fatalf("unable to generate dek: %v", err)
+	}
+
+	if _, err := openWithDEK(wrongDEK, nonce, ciphertext); err == nil {
+		t.Fatal("expected openWithDEK to reject the wrong dek")
+	}
+}
+
+func TestWriteFileAtomicReplacesExistingFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "secret.envelope")
+
+	if err := os.WriteFile(path, []byte("old"), 0o600); err != nil {
+		t.Fatalf("unable to seed existing file: %v", err)
+	}
+
+	if err := writeFileAtomic(path, []byte("new")); err != nil {
+		t.Fatalf("writeFileAtomic returned error: %v", err)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unable to read back %s: %v", path, err)
+	}
+
+	if string(got) != "new" {
+		t.Fatalf("got %q want %q", got, "new")
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("unable to list %s: %v", dir, err)
+	}
+
+	if len(entries) != 1 {
+		t.Fatalf("expected writeFileAtomic to leave exactly one file behind, found %d", len(entries))
+	}
+}
+
+func TestValidateEnvelopeVersion(t *testing.T) {
+	if err := validateEnvelopeVersion(envelopeFormatVersion); err != nil {
+		t.Fatalf("current envelope format version should be accepted: %v", err)
+	}
+
+	if err := validateEnvelopeVersion(0); err == nil {
+		t.Fatal("expected version 0 to be rejected")
+	}
+
+	if err := validateEnvelopeVersion(envelopeFormatVersion + 1); err == nil {
+		t.Fatal("expected a future envelope format version to be rejected")
+	}
+}
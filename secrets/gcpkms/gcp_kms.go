@@ -0,0 +1,56 @@
+// Package gcpkms wires the gcpkms KMS backend into the SecretsManager
+// interface, the same way secrets/awskms does for AWS KMS
+package gcpkms
+
+import (
+	"context"
+	"errors"
+
+	"github.com/0xPolygon/polygon-edge/secrets"
+	kmsapi "github.com/0xPolygon/polygon-edge/secrets/kms"
+	_ "github.com/0xPolygon/polygon-edge/secrets/kms/gcpkms" // registers kmsapi.CloudKMS
+)
+
+// SecretsManagerFactory implements the factory method
+func SecretsManagerFactory(
+	config *secrets.SecretsManagerConfig,
+	params *secrets.SecretsManagerParams,
+) (secrets.SecretsManager, error) {
+	if config.Name == "" {
+		return nil, errors.New("no node name specified for kms secrets manager")
+	}
+
+	// KmsKeyID identifies the CryptoKeyVersion to use for the validator key
+	keyID, ok := config.Extra["kms-key-id"].(string)
+	if !ok || keyID == "" {
+		return nil, errors.New("no kms key id specified for kms secrets manager")
+	}
+
+	// CredentialsFile is optional, application default credentials are
+	// used when it is not set
+	credentialsFile, _ := config.Extra["credentials-file"].(string)
+	endpoint, _ := config.Extra["endpoint"].(string)
+
+	backend, err := kmsapi.New(context.Background(), kmsapi.Options{
+		Type:            kmsapi.CloudKMS,
+		Endpoint:        endpoint,
+		CredentialsFile: credentialsFile,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	localSM, err := kmsapi.NewNetworkKeyFallback(params)
+	if err != nil {
+		return nil, err
+	}
+
+	return kmsapi.NewSecretsManager(kmsapi.ManagerConfig{
+		Type:      secrets.GcpKms,
+		ErrPrefix: "gcp kms",
+		KeyID:     keyID,
+		Backend:   backend,
+		Logger:    params.Logger.Named(string(secrets.GcpKms)),
+		LocalSM:   localSM,
+	}), nil
+}
@@ -0,0 +1,679 @@
+// Package kmip implements a SecretsManager backed by an enterprise KMIP
+// 1.4 key manager (Thales CipherTrust, Fortanix, HashiCorp Vault's KMIP
+// interface, ...), following the pattern used by Ceph-CSI's KMIP
+// integration.
+package kmip
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/gemalto/kmip-go"
+	"github.com/gemalto/kmip-go/kmip14"
+	"github.com/gemalto/kmip-go/ttlv"
+
+	"github.com/0xPolygon/polygon-edge/secrets"
+	"github.com/0xPolygon/polygon-edge/secrets/kms"
+	"github.com/hashicorp/go-hclog"
+)
+
+const (
+	// defaultTimeout is used for both reads and writes when the config
+	// does not override them
+	defaultTimeout = 10 * time.Second
+
+	// defaultTLSMinVersion pins the connection to TLS 1.2 at minimum
+	defaultTLSMinVersion = tls.VersionTLS12
+)
+
+// KmipSecretManager is a SecretsManager that stores and signs the
+// validator key on a KMIP key manager, falling back to the local secrets
+// manager for everything else (e.g. the libp2p network key)
+type KmipSecretManager struct {
+	// Logger object
+	logger hclog.Logger
+
+	// The name of the current node, used for prefixing names of secrets
+	name string
+
+	// endpoint is the host:port of the KMIP server
+	endpoint string
+
+	// uniqueID is the KMIP UniqueIdentifier of the validator signing
+	// (private) key, used for the Sign operation. It is read from config
+	// if the operator already provisioned a key, or populated by Setup
+	// after creating a new key pair on first run
+	uniqueID string
+
+	// publicKeyUniqueID is the KMIP UniqueIdentifier of the public half
+	// of the validator key pair, used for the Get operation in
+	// GetSecretInfo. A KMIP private key object's KeyMaterial is not a
+	// public SPKI-encoded key, so GetSecretInfo must fetch this object
+	// instead of uniqueID
+	publicKeyUniqueID string
+
+	tlsConfig *tls.Config
+
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+
+	// libp2p key uses the local secrets manager
+	localSM secrets.SecretsManager
+
+	// init phase, cache the validator pubkey/address
+	secretInfo *secrets.SecretInfo
+}
+
+// SecretsManagerFactory implements the factory method
+func SecretsManagerFactory(
+	config *secrets.SecretsManagerConfig,
+	params *secrets.SecretsManagerParams,
+) (secrets.SecretsManager, error) {
+	kmipManager := &KmipSecretManager{
+		logger:       params.Logger.Named(string(secrets.Kmip)),
+		readTimeout:  defaultTimeout,
+		writeTimeout: defaultTimeout,
+	}
+
+	if config.Name == "" {
+		return nil, errors.New("no node name specified for kmip secrets manager")
+	}
+
+	kmipManager.name = config.Name
+
+	endpoint, ok := config.Extra["endpoint"].(string)
+	if !ok || endpoint == "" {
+		return nil, errors.New("no endpoint specified for kmip secrets manager")
+	}
+
+	kmipManager.endpoint = endpoint
+
+	// UniqueIdentifier is optional: if unset, Setup creates a new
+	// validator key pair on first run. When it is set, the UID of the
+	// linked public key object must be supplied too, since Get on the
+	// private key's UID does not return SPKI-encoded key material
+	kmipManager.uniqueID, _ = config.Extra["unique-identifier"].(string)
+	kmipManager.publicKeyUniqueID, _ = config.Extra["public-key-unique-identifier"].(string)
+
+	if kmipManager.uniqueID != "" && kmipManager.publicKeyUniqueID == "" {
+		return nil, errors.New("public-key-unique-identifier must be set alongside unique-identifier")
+	}
+
+	if seconds, ok := config.Extra["read-timeout-seconds"].(float64); ok && seconds > 0 {
+		kmipManager.readTimeout = time.Duration(seconds) * time.Second
+	}
+
+	if seconds, ok := config.Extra["write-timeout-seconds"].(float64); ok && seconds > 0 {
+		kmipManager.writeTimeout = time.Duration(seconds) * time.Second
+	}
+
+	tlsConfig, err := loadTLSConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	kmipManager.tlsConfig = tlsConfig
+
+	if err := kmipManager.Setup(); err != nil {
+		return nil, err
+	}
+
+	kmipManager.localSM, err = kms.NewNetworkKeyFallback(params)
+	if err != nil {
+		return nil, err
+	}
+
+	return kmipManager, nil
+}
+
+// loadTLSConfig builds a mutually-authenticated TLS config from the client
+// cert/key/CA paths in config.Extra. When a path is not set, the file is
+// taken from the directory given by the KMIP_SECRET_NAME environment
+// variable (tls.crt, tls.key and ca.crt), which is where a Kubernetes
+// Secret mounted as a volume alongside a validator pod places them. The
+// Kubernetes API itself is not queried
+func loadTLSConfig(config *secrets.SecretsManagerConfig) (*tls.Config, error) {
+	secretDir := os.Getenv("KMIP_SECRET_NAME")
+
+	certPath, _ := config.Extra["client-cert-path"].(string)
+	if certPath == "" && secretDir != "" {
+		certPath = secretDir + "/tls.crt"
+	}
+
+	keyPath, _ := config.Extra["client-key-path"].(string)
+	if keyPath == "" && secretDir != "" {
+		keyPath = secretDir + "/tls.key"
+	}
+
+	caPath, _ := config.Extra["ca-cert-path"].(string)
+	if caPath == "" && secretDir != "" {
+		caPath = secretDir + "/ca.crt"
+	}
+
+	if certPath == "" || keyPath == "" || caPath == "" {
+		return nil, errors.New("kmip: client-cert-path, client-key-path and ca-cert-path must be set " +
+			"(directly, or via KMIP_SECRET_NAME)")
+	}
+
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("kmip: unable to load client certificate: %w", err)
+	}
+
+	caCert, err := os.ReadFile(caPath)
+	if err != nil {
+		return nil, fmt.Errorf("kmip: unable to read ca certificate: %w", err)
+	}
+
+	caPool := x509.NewCertPool()
+	if !caPool.AppendCertsFromPEM(caCert) {
+		return nil, errors.New("kmip: unable to parse ca certificate")
+	}
+
+	return &tls.Config{
+		MinVersion:   defaultTLSMinVersion,
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      caPool,
+	}, nil
+}
+
+// Setup opens a mutually-authenticated TLS connection to the KMIP server,
+// confirms it speaks KMIP 1.4 and resolves the validator key pair,
+// creating a new secp256k1 key pair on first run if no UniqueIdentifier
+// was configured
+func (k *KmipSecretManager) Setup() error {
+	conn, err := k.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	client := ttlv.NewClient(conn)
+
+	if err := k.discoverVersions(client); err != nil {
+		return err
+	}
+
+	if k.uniqueID != "" {
+		return nil
+	}
+
+	privateKeyUID, publicKeyUID, err := k.locateValidatorKey(client)
+	if err == nil {
+		k.uniqueID = privateKeyUID
+		k.publicKeyUniqueID = publicKeyUID
+
+		return nil
+	}
+
+	privateKeyUID, publicKeyUID, err = k.createValidatorKeyPair(client)
+	if err != nil {
+		return err
+	}
+
+	k.uniqueID = privateKeyUID
+	k.publicKeyUniqueID = publicKeyUID
+
+	return nil
+}
+
+func (k *KmipSecretManager) dial() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: k.writeTimeout}
+
+	conn, err := tls.DialWithDialer(dialer, "tcp", k.endpoint, k.tlsConfig)
+	if err != nil {
+		return nil, fmt.Errorf("kmip: unable to connect to %s: %w", k.endpoint, err)
+	}
+
+	return conn, nil
+}
+
+// discoverVersions confirms the server supports KMIP 1.4, per the spec's
+// recommended connection handshake
+func (k *KmipSecretManager) discoverVersions(client *ttlv.Client) error {
+	req := kmip.RequestMessage{
+		RequestHeader: kmip.RequestHeader{
+			ProtocolVersion: kmip.ProtocolVersion{ProtocolVersionMajor: 1, ProtocolVersionMinor: 4},
+			BatchCount:      1,
+		},
+		BatchItem: []kmip.RequestBatchItem{{
+			Operation: kmip14.OperationDiscoverVersions,
+			RequestPayload: kmip.DiscoverVersionsRequestPayload{
+				ProtocolVersion: []kmip.ProtocolVersion{
+					{ProtocolVersionMajor: 1, ProtocolVersionMinor: 4},
+				},
+			},
+		}},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), k.readTimeout)
+	defer cancel()
+
+	resp, err := client.Send(ctx, req)
+	if err != nil {
+		return fmt.Errorf("kmip: discover versions failed: %w", err)
+	}
+
+	var payload struct {
+		ProtocolVersion []kmip.ProtocolVersion
+	}
+
+	if err := resp.DecodeBatchItem(0, &payload); err != nil {
+		return fmt.Errorf("kmip: unable to decode discover versions response: %w", err)
+	}
+
+	for _, v := range payload.ProtocolVersion {
+		if v.ProtocolVersionMajor == 1 && v.ProtocolVersionMinor == 4 {
+			return nil
+		}
+	}
+
+	return errors.New("kmip: server does not support KMIP 1.4")
+}
+
+// locateValidatorKey finds a previously-provisioned validator private key
+// by the same Name attribute createValidatorKeyPair sets, and resolves the
+// public key object linked to it. Filtering by name matters on any server
+// that hosts more than one object under the client's credentials (the
+// common case for a shared client certificate across validators): without
+// it, Locate would return an arbitrary object and this node could end up
+// signing with another node's key
+func (k *KmipSecretManager) locateValidatorKey(client *ttlv.Client) (privateKeyUID, publicKeyUID string, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), k.readTimeout)
+	defer cancel()
+
+	name := k.name + "-validator"
+
+	req := kmip.RequestMessage{
+		RequestHeader: kmip.RequestHeader{
+			ProtocolVersion: kmip.ProtocolVersion{ProtocolVersionMajor: 1, ProtocolVersionMinor: 4},
+			BatchCount:      1,
+		},
+		BatchItem: []kmip.RequestBatchItem{{
+			Operation: kmip14.OperationLocate,
+			RequestPayload: kmip.LocateRequestPayload{
+				Attribute: []kmip.Attribute{
+					{AttributeName: "Name", AttributeValue: name},
+				},
+			},
+		}},
+	}
+
+	resp, err := client.Send(ctx, req)
+	if err != nil {
+		return "", "", fmt.Errorf("kmip: locate failed: %w", err)
+	}
+
+	ids, err := decodeLocatedUniqueIdentifiers(resp)
+	if err != nil {
+		return "", "", err
+	}
+
+	switch len(ids) {
+	case 0:
+		return "", "", fmt.Errorf("kmip: locate found no key named %q", name)
+	case 1:
+		// exactly one match, as expected
+	default:
+		return "", "", fmt.Errorf("kmip: locate found %d keys named %q, expected exactly one", len(ids), name)
+	}
+
+	privateKeyUID = ids[0]
+
+	publicKeyUID, err = k.locatePublicKeyLink(client, privateKeyUID)
+	if err != nil {
+		return "", "", err
+	}
+
+	return privateKeyUID, publicKeyUID, nil
+}
+
+// locatePublicKeyLink resolves the UID of the public key object linked to
+// privateKeyUID via its "Link" attribute (KMIP 1.4 section 3.36). A Get on
+// the private key's own UID returns its private KeyMaterial, not an
+// SPKI-encoded public key, so GetSecretInfo needs this UID instead
+func (k *KmipSecretManager) locatePublicKeyLink(client *ttlv.Client, privateKeyUID string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), k.readTimeout)
+	defer cancel()
+
+	req := kmip.RequestMessage{
+		RequestHeader: kmip.RequestHeader{
+			ProtocolVersion: kmip.ProtocolVersion{ProtocolVersionMajor: 1, ProtocolVersionMinor: 4},
+			BatchCount:      1,
+		},
+		BatchItem: []kmip.RequestBatchItem{{
+			Operation: kmip14.OperationGetAttributes,
+			RequestPayload: kmip.GetAttributesRequestPayload{
+				UniqueIdentifier: privateKeyUID,
+				AttributeName:    []string{"Link"},
+			},
+		}},
+	}
+
+	resp, err := client.Send(ctx, req)
+	if err != nil {
+		return "", fmt.Errorf("kmip: get attributes failed: %w", err)
+	}
+
+	var payload struct {
+		Attribute []struct {
+			AttributeName  string
+			AttributeValue struct {
+				LinkType               kmip14.LinkType
+				LinkedObjectIdentifier string
+			}
+		}
+	}
+
+	if err := resp.DecodeBatchItem(0, &payload); err != nil {
+		return "", fmt.Errorf("kmip: unable to decode link attribute: %w", err)
+	}
+
+	for _, attr := range payload.Attribute {
+		if attr.AttributeName == "Link" && attr.AttributeValue.LinkType == kmip14.LinkTypePublicKeyLink {
+			return attr.AttributeValue.LinkedObjectIdentifier, nil
+		}
+	}
+
+	return "", errors.New("kmip: private key has no linked public key")
+}
+
+// createValidatorKeyPair has the KMIP server generate a new secp256k1 key
+// pair for a node that has never provisioned a validator key before.
+// Register only imports externally-supplied key material and produces a
+// single UID; CreateKeyPair is the operation that actually generates a key
+// on the server, and is the one that returns the private and public key
+// UIDs separately
+func (k *KmipSecretManager) createValidatorKeyPair(client *ttlv.Client) (privateKeyUID, publicKeyUID string, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), k.writeTimeout)
+	defer cancel()
+
+	req := kmip.RequestMessage{
+		RequestHeader: kmip.RequestHeader{
+			ProtocolVersion: kmip.ProtocolVersion{ProtocolVersionMajor: 1, ProtocolVersionMinor: 4},
+			BatchCount:      1,
+		},
+		BatchItem: []kmip.RequestBatchItem{{
+			Operation: kmip14.OperationCreateKeyPair,
+			RequestPayload: kmip.CreateKeyPairRequestPayload{
+				CommonTemplateAttribute: kmip.TemplateAttribute{
+					Attribute: []kmip.Attribute{
+						{AttributeName: "Cryptographic Algorithm", AttributeValue: kmip14.CryptographicAlgorithmECDSA},
+						// Without domain parameters the server picks its own
+						// default EC curve (typically NIST P-256), not
+						// secp256k1. KMIP 1.4 added SECP256K1 to the
+						// RecommendedCurve enumeration for this exact case
+						{
+							AttributeName: "Cryptographic Domain Parameters",
+							AttributeValue: kmip.CryptographicDomainParameters{
+								RecommendedCurve: kmip14.RecommendedCurveSECP256K1,
+							},
+						},
+					},
+				},
+				PrivateKeyTemplateAttribute: kmip.TemplateAttribute{
+					Attribute: []kmip.Attribute{
+						{AttributeName: "Name", AttributeValue: k.name + "-validator"},
+						{AttributeName: "Cryptographic Usage Mask", AttributeValue: kmip14.CryptographicUsageMaskSign},
+					},
+				},
+				PublicKeyTemplateAttribute: kmip.TemplateAttribute{
+					Attribute: []kmip.Attribute{
+						{AttributeName: "Name", AttributeValue: k.name + "-validator-pub"},
+						{AttributeName: "Cryptographic Usage Mask", AttributeValue: kmip14.CryptographicUsageMaskVerify},
+					},
+				},
+			},
+		}},
+	}
+
+	resp, err := client.Send(ctx, req)
+	if err != nil {
+		return "", "", fmt.Errorf("kmip: create key pair failed: %w", err)
+	}
+
+	var payload struct {
+		PrivateKeyUniqueIdentifier string
+		PublicKeyUniqueIdentifier  string
+	}
+
+	if err := resp.DecodeBatchItem(0, &payload); err != nil {
+		return "", "", fmt.Errorf("kmip: unable to decode create key pair response: %w", err)
+	}
+
+	if payload.PrivateKeyUniqueIdentifier == "" || payload.PublicKeyUniqueIdentifier == "" {
+		return "", "", errors.New("kmip: create key pair response missing a unique identifier")
+	}
+
+	return payload.PrivateKeyUniqueIdentifier, payload.PublicKeyUniqueIdentifier, nil
+}
+
+// decodeLocatedUniqueIdentifiers pulls every UniqueIdentifier a Locate call
+// matched out of the first batch item of a KMIP response. The caller is
+// responsible for checking how many came back: Locate can legitimately
+// match zero, one or many objects
+func decodeLocatedUniqueIdentifiers(resp *ttlv.Message) ([]string, error) {
+	var payload struct {
+		UniqueIdentifier []string
+	}
+
+	if err := resp.DecodeBatchItem(0, &payload); err != nil {
+		return nil, fmt.Errorf("kmip: unable to decode locate response: %w", err)
+	}
+
+	return payload.UniqueIdentifier, nil
+}
+
+// GetSecret gets the secret by name
+func (k *KmipSecretManager) GetSecret(name string) ([]byte, error) {
+	switch name {
+	case secrets.ValidatorKey:
+		info, err := k.GetSecretInfo(name)
+		if err != nil {
+			return nil, err
+		}
+
+		return []byte(info.Pubkey), nil
+
+	case secrets.NetworkKey:
+		return k.localSM.GetSecret(name)
+
+	default:
+		return nil, errors.New("not support getsecret name")
+	}
+}
+
+// SetSecret sets the secret to a provided value
+func (k *KmipSecretManager) SetSecret(name string, value []byte) error {
+	switch name {
+	case secrets.ValidatorKey:
+		return errors.New("kmip not support setsecret")
+
+	case secrets.NetworkKey:
+		return k.localSM.SetSecret(name, value)
+
+	default:
+		return errors.New("not support setsecret name")
+	}
+}
+
+// HasSecret checks if the secret is present
+func (k *KmipSecretManager) HasSecret(name string) bool {
+	switch name {
+	case secrets.ValidatorKey:
+		return k.uniqueID != ""
+
+	case secrets.NetworkKey:
+		return k.localSM.HasSecret(name)
+
+	default:
+		return true
+	}
+}
+
+// RemoveSecret removes the secret from storage
+func (k *KmipSecretManager) RemoveSecret(name string) error {
+	switch name {
+	case secrets.ValidatorKey:
+		return errors.New("kmip not support RemoveSecret")
+
+	case secrets.NetworkKey:
+		return k.localSM.RemoveSecret(name)
+
+	default:
+		return errors.New("not support RemoveSecret name")
+	}
+}
+
+// SignBySecret signs data with the KMIP-held validator key and returns the
+// 65-byte Ethereum-style signature (R || S || V), assembled the same way
+// the awskms backend does
+func (k *KmipSecretManager) SignBySecret(key string, data []byte) ([]byte, error) {
+	start := time.Now()
+
+	info, err := k.GetSecretInfo(key)
+	if err != nil {
+		kms.ObserveSign(string(secrets.Kmip), start, err)
+		kms.AuditSign(k.logger, string(secrets.Kmip), k.uniqueID, data, start, err)
+
+		return nil, err
+	}
+
+	conn, err := k.dial()
+	if err != nil {
+		kms.ObserveSign(string(secrets.Kmip), start, err)
+		kms.AuditSign(k.logger, string(secrets.Kmip), k.uniqueID, data, start, err)
+
+		return nil, err
+	}
+	defer conn.Close()
+
+	client := ttlv.NewClient(conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), k.writeTimeout)
+	defer cancel()
+
+	req := kmip.RequestMessage{
+		RequestHeader: kmip.RequestHeader{
+			ProtocolVersion: kmip.ProtocolVersion{ProtocolVersionMajor: 1, ProtocolVersionMinor: 4},
+			BatchCount:      1,
+		},
+		BatchItem: []kmip.RequestBatchItem{{
+			Operation: kmip14.OperationSign,
+			RequestPayload: kmip.SignRequestPayload{
+				UniqueIdentifier: k.uniqueID,
+				Data:             data,
+				CryptographicParameters: kmip.CryptographicParameters{
+					CryptographicAlgorithm: kmip14.CryptographicAlgorithmECDSA,
+					HashingAlgorithm:       kmip14.HashingAlgorithmSHA_256,
+				},
+			},
+		}},
+	}
+
+	resp, err := client.Send(ctx, req)
+	if err != nil {
+		kms.ObserveSign(string(secrets.Kmip), start, err)
+		kms.AuditSign(k.logger, string(secrets.Kmip), k.uniqueID, data, start, err)
+
+		return nil, fmt.Errorf("kmip: sign failed: %w", err)
+	}
+
+	var payload struct {
+		SignatureData []byte
+	}
+
+	if err := resp.DecodeBatchItem(0, &payload); err != nil {
+		kms.ObserveSign(string(secrets.Kmip), start, err)
+		kms.AuditSign(k.logger, string(secrets.Kmip), k.uniqueID, data, start, err)
+
+		return nil, fmt.Errorf("kmip: unable to decode signature: %w", err)
+	}
+
+	sig, err := kms.SignatureDERToEthereum(payload.SignatureData, data, info.Address)
+
+	kms.ObserveSign(string(secrets.Kmip), start, err)
+	kms.AuditSign(k.logger, string(secrets.Kmip), k.uniqueID, data, start, err)
+
+	return sig, err
+}
+
+// GetSecretInfo returns the public key and Ethereum address derived from
+// the KMIP-held validator key
+func (k *KmipSecretManager) GetSecretInfo(name string) (*secrets.SecretInfo, error) {
+	if name != secrets.ValidatorKey {
+		return nil, errors.New("not support GetSecretInfo name")
+	}
+
+	if k.secretInfo != nil {
+		return k.secretInfo, nil
+	}
+
+	conn, err := k.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	client := ttlv.NewClient(conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), k.readTimeout)
+	defer cancel()
+
+	// Get must target the public key object: a Get on the private key's
+	// UID returns its private KeyMaterial, not an SPKI-encoded public key
+	req := kmip.RequestMessage{
+		RequestHeader: kmip.RequestHeader{
+			ProtocolVersion: kmip.ProtocolVersion{ProtocolVersionMajor: 1, ProtocolVersionMinor: 4},
+			BatchCount:      1,
+		},
+		BatchItem: []kmip.RequestBatchItem{{
+			Operation: kmip14.OperationGet,
+			RequestPayload: kmip.GetRequestPayload{
+				UniqueIdentifier: k.publicKeyUniqueID,
+			},
+		}},
+	}
+
+	resp, err := client.Send(ctx, req)
+
+	kms.ObserveGetPublicKey(string(secrets.Kmip), err)
+
+	if err != nil {
+		return nil, fmt.Errorf("kmip: get failed: %w", err)
+	}
+
+	var payload struct {
+		Object struct {
+			KeyBlock struct {
+				KeyValue struct {
+					KeyMaterial []byte
+				}
+			}
+		}
+	}
+
+	if err := resp.DecodeBatchItem(0, &payload); err != nil {
+		return nil, fmt.Errorf("kmip: unable to decode public key: %w", err)
+	}
+
+	info, err := kms.PublicKeyDERToSecretInfo(payload.Object.KeyBlock.KeyValue.KeyMaterial)
+	if err != nil {
+		return nil, err
+	}
+
+	k.secretInfo = info
+
+	return info, nil
+}
+
+// GetSecretsManagerType returns the SecretsManagerType
+func (k *KmipSecretManager) GetSecretsManagerType() secrets.SecretsManagerType {
+	return secrets.Kmip
+}
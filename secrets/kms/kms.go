@@ -0,0 +1,143 @@
+// Package kms defines a backend-agnostic interface for asymmetric signing
+// services, modeled after the kms/apiv1 split used by smallstep/certificates.
+// Concrete backends (awskms, gcpkms, pkcs11, ...) implement the KMS
+// interface and register themselves through Register, so that callers only
+// ever depend on this package and never on a specific cloud/HSM SDK.
+package kms
+
+import (
+	"context"
+	"fmt"
+)
+
+// Type identifies a concrete KMS backend implementation
+type Type string
+
+const (
+	// AmazonKMS is the AWS KMS backend
+	AmazonKMS Type = "awskms"
+
+	// CloudKMS is the Google Cloud KMS backend
+	CloudKMS Type = "gcpkms"
+
+	// PKCS11 is the backend talking to an HSM through a PKCS#11 module
+	PKCS11 Type = "pkcs11"
+)
+
+// Options carries the configuration needed to construct any backend. Each
+// backend only reads the fields it needs; it is populated from the
+// SecretsManagerConfig.Extra map of the SecretsManager wrapping it
+type Options struct {
+	// Type selects which registered backend New should construct
+	Type Type
+
+	// Region is the cloud region the key lives in (awskms)
+	Region string
+
+	// Endpoint optionally overrides the backend service endpoint
+	// (awskms, gcpkms), e.g. to target a local emulator
+	Endpoint string
+
+	// KeyID identifies the signing key within the backend: a KMS key
+	// id/alias/ARN for awskms, a CryptoKeyVersion resource name for
+	// gcpkms, or a PKCS#11 object label for pkcs11
+	KeyID string
+
+	// CredentialsFile is an optional path to a service account /
+	// credentials file (gcpkms)
+	CredentialsFile string
+
+	// ModulePath is the path to the vendor PKCS#11 shared library
+	// (pkcs11)
+	ModulePath string
+
+	// TokenLabel and Pin authenticate against a PKCS#11 slot (pkcs11)
+	TokenLabel string
+	Pin        string
+}
+
+// GetPublicKeyRequest requests the public key material for KeyID
+type GetPublicKeyRequest struct {
+	KeyID string
+}
+
+// GetPublicKeyResponse carries the ASN.1 DER-encoded SubjectPublicKeyInfo
+// for the requested key
+type GetPublicKeyResponse struct {
+	PublicKeyDER []byte
+}
+
+// SignDigestRequest asks the backend to sign a pre-hashed digest
+type SignDigestRequest struct {
+	KeyID string
+
+	// Digest is the 32-byte SHA-256 digest to sign
+	Digest []byte
+}
+
+// SignDigestResponse carries the ASN.1 DER-encoded ECDSA-Sig-Value
+// (R, S) produced by the backend
+type SignDigestResponse struct {
+	SignatureDER []byte
+}
+
+// CreateKeyRequest asks the backend to create a new secp256k1 signing key
+type CreateKeyRequest struct {
+	// Name is a backend-specific hint for the new key (alias, label, ...)
+	Name string
+}
+
+// CreateKeyResponse carries the identifier and public key of a freshly
+// created key
+type CreateKeyResponse struct {
+	KeyID        string
+	PublicKeyDER []byte
+}
+
+// KMS is the interface every pluggable signing backend must implement.
+// Implementations are expected to produce secp256k1/ECDSA signatures
+// suitable for validator keys
+type KMS interface {
+	// GetPublicKey returns the DER-encoded public key for req.KeyID
+	GetPublicKey(ctx context.Context, req *GetPublicKeyRequest) (*GetPublicKeyResponse, error)
+
+	// SignDigest signs req.Digest with req.KeyID
+	SignDigest(ctx context.Context, req *SignDigestRequest) (*SignDigestResponse, error)
+
+	// CreateKey provisions a new signing key
+	CreateKey(ctx context.Context, req *CreateKeyRequest) (*CreateKeyResponse, error)
+
+	// Close releases any resources (connections, sessions, ...) held by
+	// the backend
+	Close() error
+}
+
+// NewFunc constructs a KMS backend from Options
+type NewFunc func(ctx context.Context, opts Options) (KMS, error)
+
+// registry holds the constructors registered by each backend package
+var registry = map[Type]NewFunc{}
+
+// Register makes a backend constructor available to New. Backend packages
+// call this from an init() function so that importing them for their
+// side effect is enough to make them selectable via Options.Type
+func Register(t Type, fn NewFunc) {
+	registry[t] = fn
+}
+
+// New constructs the backend registered under opts.Type, wrapped with the
+// Prometheus metrics in metrics.go so every backend is observable without
+// having to instrument itself
+func New(ctx context.Context, opts Options) (KMS, error) {
+	fn, ok := registry[opts.Type]
+	if !ok {
+		return nil, fmt.Errorf("kms: no backend registered for type %q", opts.Type)
+	}
+
+	backend, err := fn(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return instrument(string(opts.Type), backend), nil
+}
@@ -0,0 +1,212 @@
+package kms
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/secrets"
+	"github.com/hashicorp/go-hclog"
+)
+
+// ManagerConfig carries everything SecretsManager needs to wrap a KMS
+// backend: awskms, gcpkms and pkcs11 all built an identical SecretsManager
+// around their backend before this type existed, so a backend package now
+// only has to fill in a ManagerConfig and hand it to NewSecretsManager
+type ManagerConfig struct {
+	// Type is the SecretsManagerType reported by GetSecretsManagerType
+	Type secrets.SecretsManagerType
+
+	// ErrPrefix names the backend in "not supported" error messages,
+	// e.g. "aws kms", "gcp kms", "pkcs11"
+	ErrPrefix string
+
+	// KeyID identifies the validator key within Backend
+	KeyID string
+
+	// Backend does the actual GetPublicKey/SignDigest work
+	Backend KMS
+
+	// Logger is used for the signing audit trail
+	Logger hclog.Logger
+
+	// LocalSM stores the libp2p network key, which has no equivalent
+	// concept in a KMS/HSM
+	LocalSM secrets.SecretsManager
+
+	// WrapSecret and UnwrapSecret optionally envelope-encrypt the
+	// NetworkKey before/after it reaches LocalSM. Both are nil by
+	// default, in which case LocalSM sees the key in plaintext
+	WrapSecret   func([]byte) ([]byte, error)
+	UnwrapSecret func([]byte) ([]byte, error)
+}
+
+// SecretsManager is a generic SecretsManager backed by a KMS implementation:
+// it signs and serves the validator key straight from Backend, and falls
+// back to LocalSM for everything else (e.g. the libp2p network key)
+type SecretsManager struct {
+	cfg ManagerConfig
+
+	// init phase, cache the validator pubkey/address
+	secretInfo *secrets.SecretInfo
+}
+
+// NewSecretsManager builds a SecretsManager from cfg
+func NewSecretsManager(cfg ManagerConfig) *SecretsManager {
+	return &SecretsManager{cfg: cfg}
+}
+
+// Setup is a no-op; cfg.Backend is already connected by the time
+// NewSecretsManager is called
+func (m *SecretsManager) Setup() error {
+	return nil
+}
+
+// GetSecret gets the secret by name
+func (m *SecretsManager) GetSecret(name string) ([]byte, error) {
+	switch name {
+	case secrets.ValidatorKey:
+		return m.GetSecretFromKms(name)
+
+	case secrets.NetworkKey:
+		raw, err := m.cfg.LocalSM.GetSecret(name)
+		if err != nil {
+			return nil, err
+		}
+
+		if m.cfg.UnwrapSecret == nil {
+			return raw, nil
+		}
+
+		return m.cfg.UnwrapSecret(raw)
+
+	default:
+		return nil, errors.New("not support getsecret name")
+	}
+}
+
+// GetSecretFromKms fetches the DER-encoded public key for the validator
+// key. A KMS/HSM never exposes the private key material, so this is the
+// only representation of the secret available outside of SignBySecret
+func (m *SecretsManager) GetSecretFromKms(name string) ([]byte, error) {
+	resp, err := m.cfg.Backend.GetPublicKey(context.Background(), &GetPublicKeyRequest{
+		KeyID: m.cfg.KeyID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.PublicKeyDER, nil
+}
+
+// SetSecret sets the secret to a provided value
+func (m *SecretsManager) SetSecret(name string, value []byte) error {
+	switch name {
+	case secrets.ValidatorKey:
+		return fmt.Errorf("%s not support setsecret", m.cfg.ErrPrefix)
+
+	case secrets.NetworkKey:
+		if m.cfg.WrapSecret == nil {
+			return m.cfg.LocalSM.SetSecret(name, value)
+		}
+
+		wrapped, err := m.cfg.WrapSecret(value)
+		if err != nil {
+			return err
+		}
+
+		return m.cfg.LocalSM.SetSecret(name, wrapped)
+
+	default:
+		return errors.New("not support setsecret name")
+	}
+}
+
+// HasSecret checks if the secret is present
+func (m *SecretsManager) HasSecret(name string) bool {
+	switch name {
+	case secrets.ValidatorKey:
+		return true
+
+	case secrets.NetworkKey:
+		return m.cfg.LocalSM.HasSecret(name)
+
+	default:
+		return true
+	}
+}
+
+// RemoveSecret removes the secret from storage
+func (m *SecretsManager) RemoveSecret(name string) error {
+	switch name {
+	case secrets.ValidatorKey:
+		return fmt.Errorf("%s not support RemoveSecret", m.cfg.ErrPrefix)
+
+	case secrets.NetworkKey:
+		return m.cfg.LocalSM.RemoveSecret(name)
+
+	default:
+		return errors.New("not support RemoveSecret name")
+	}
+}
+
+// SignBySecret signs data with the KMS-held validator key and returns the
+// 65-byte Ethereum-style signature (R || S || V)
+func (m *SecretsManager) SignBySecret(key string, data []byte) ([]byte, error) {
+	start := time.Now()
+
+	info, err := m.GetSecretInfo(key)
+	if err != nil {
+		AuditSign(m.cfg.Logger, string(m.cfg.Type), m.cfg.KeyID, data, start, err)
+
+		return nil, err
+	}
+
+	resp, err := m.cfg.Backend.SignDigest(context.Background(), &SignDigestRequest{
+		KeyID:  m.cfg.KeyID,
+		Digest: data,
+	})
+	if err != nil {
+		AuditSign(m.cfg.Logger, string(m.cfg.Type), m.cfg.KeyID, data, start, err)
+
+		return nil, err
+	}
+
+	sig, err := SignatureDERToEthereum(resp.SignatureDER, data, info.Address)
+
+	AuditSign(m.cfg.Logger, string(m.cfg.Type), m.cfg.KeyID, data, start, err)
+
+	return sig, err
+}
+
+// GetSecretInfo returns the public key and Ethereum address derived from
+// the KMS-held validator key
+func (m *SecretsManager) GetSecretInfo(name string) (*secrets.SecretInfo, error) {
+	if name != secrets.ValidatorKey {
+		return nil, errors.New("not support GetSecretInfo name")
+	}
+
+	if m.secretInfo != nil {
+		return m.secretInfo, nil
+	}
+
+	der, err := m.GetSecretFromKms(name)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := PublicKeyDERToSecretInfo(der)
+	if err != nil {
+		return nil, err
+	}
+
+	m.secretInfo = info
+
+	return info, nil
+}
+
+// GetSecretsManagerType returns the SecretsManagerType
+func (m *SecretsManager) GetSecretsManagerType() secrets.SecretsManagerType {
+	return m.cfg.Type
+}
@@ -0,0 +1,273 @@
+package kms
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/asn1"
+	"errors"
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/secrets"
+	"github.com/hashicorp/go-hclog"
+)
+
+// fakeKMS is a minimal in-memory KMS backend used to drive SecretsManager
+// without any network access
+type fakeKMS struct {
+	publicKeyDER []byte
+	signatureDER []byte
+	signErr      error
+}
+
+func (f *fakeKMS) GetPublicKey(_ context.Context, _ *GetPublicKeyRequest) (*GetPublicKeyResponse, error) {
+	return &GetPublicKeyResponse{PublicKeyDER: f.publicKeyDER}, nil
+}
+
+func (f *fakeKMS) SignDigest(_ context.Context, _ *SignDigestRequest) (*SignDigestResponse, error) {
+	if f.signErr != nil {
+		return nil, f.signErr
+	}
+
+	return &SignDigestResponse{SignatureDER: f.signatureDER}, nil
+}
+
+func (f *fakeKMS) CreateKey(_ context.Context, _ *CreateKeyRequest) (*CreateKeyResponse, error) {
+	return nil, errors.New("fakeKMS: CreateKey not implemented")
+}
+
+func (f *fakeKMS) Close() error {
+	return nil
+}
+
+// fakeLocalSM is an in-memory stand-in for the local secrets manager used
+// to store the libp2p network key
+type fakeLocalSM struct {
+	secrets map[string][]byte
+}
+
+func newFakeLocalSM() *fakeLocalSM {
+	return &fakeLocalSM{secrets: map[string][]byte{}}
+}
+
+func (f *fakeLocalSM) Setup() error { return nil }
+
+func (f *fakeLocalSM) GetSecret(name string) ([]byte, error) {
+	value, ok := f.secrets[name]
+	if !ok {
+		return nil, errors.New("fakeLocalSM: secret not found")
+	}
+
+	return value, nil
+}
+
+func (f *fakeLocalSM) SetSecret(name string, value []byte) error {
+	f.secrets[name] = value
+
+	return nil
+}
+
+func (f *fakeLocalSM) HasSecret(name string) bool {
+	_, ok := f.secrets[name]
+
+	return ok
+}
+
+func (f *fakeLocalSM) RemoveSecret(name string) error {
+	delete(f.secrets, name)
+
+	return nil
+}
+
+func (f *fakeLocalSM) SignBySecret(string, []byte) ([]byte, error) {
+	return nil, errors.New("fakeLocalSM: SignBySecret not implemented")
+}
+
+func (f *fakeLocalSM) GetSecretInfo(string) (*secrets.SecretInfo, error) {
+	return nil, errors.New("fakeLocalSM: GetSecretInfo not implemented")
+}
+
+func (f *fakeLocalSM) GetSecretsManagerType() secrets.SecretsManagerType {
+	return ""
+}
+
+// newFakeValidatorKey generates a secp256k1 key pair and a matching DER
+// signature over digest, for tests that drive SignBySecret/GetSecretInfo
+func newFakeValidatorKey(t *testing.T, digest []byte) (pubDER, sigDER []byte) {
+	t.Helper()
+
+	priv := generateTestKey(t)
+
+	pubDER, err := MarshalSecp256k1PublicKeyToDER(&priv.PublicKey)
+	if err != nil {
+		t.Fatalf("unable to marshal public key: %v", err)
+	}
+
+	r, s, err := ecdsa.Sign(rand.Reader, priv, digest)
+	if err != nil {
+		t.Fatalf("unable to sign digest: %v", err)
+	}
+
+	sigDER, err = asn1.Marshal(ecdsaSigValue{R: r, S: s})
+	if err != nil {
+		t.Fatalf("unable to marshal signature: %v", err)
+	}
+
+	return pubDER, sigDER
+}
+
+func TestSecretsManagerGetSecretInfoCachesAfterFirstCall(t *testing.T) {
+	digest := make([]byte, 32)
+	if _, err := rand.Read(digest); err != nil {
+		t.Fatalf("unable to generate digest: %v", err)
+	}
+
+	pubDER, _ := newFakeValidatorKey(t, digest)
+
+	backend := &fakeKMS{publicKeyDER: pubDER}
+
+	mgr := NewSecretsManager(ManagerConfig{
+		Type:    secrets.AwsKms,
+		KeyID:   "test-key",
+		Backend: backend,
+		Logger:  hclog.NewNullLogger(),
+		LocalSM: newFakeLocalSM(),
+	})
+
+	info, err := mgr.GetSecretInfo(secrets.ValidatorKey)
+	if err != nil {
+		t.Fatalf("GetSecretInfo returned error: %v", err)
+	}
+
+	// Flip the backend's response to confirm the second call hits the
+	// cache instead of calling GetPublicKey again
+	backend.publicKeyDER = nil
+
+	cached, err := mgr.GetSecretInfo(secrets.ValidatorKey)
+	if err != nil {
+		t.Fatalf("GetSecretInfo (cached) returned error: %v", err)
+	}
+
+	if cached.Address != info.Address {
+		t.Fatal("expected the cached GetSecretInfo call to return the same SecretInfo")
+	}
+}
+
+func TestSecretsManagerSignBySecret(t *testing.T) {
+	digest := make([]byte, 32)
+	if _, err := rand.Read(digest); err != nil {
+		t.Fatalf("unable to generate digest: %v", err)
+	}
+
+	pubDER, sigDER := newFakeValidatorKey(t, digest)
+
+	backend := &fakeKMS{publicKeyDER: pubDER, signatureDER: sigDER}
+
+	mgr := NewSecretsManager(ManagerConfig{
+		Type:    secrets.AwsKms,
+		KeyID:   "test-key",
+		Backend: backend,
+		Logger:  hclog.NewNullLogger(),
+		LocalSM: newFakeLocalSM(),
+	})
+
+	sig, err := mgr.SignBySecret(secrets.ValidatorKey, digest)
+	if err != nil {
+		t.Fatalf("SignBySecret returned error: %v", err)
+	}
+
+	if len(sig) != 65 {
+		t.Fatalf("expected a 65-byte signature, got %d bytes", len(sig))
+	}
+}
+
+func TestSecretsManagerSignBySecretPropagatesBackendError(t *testing.T) {
+	digest := make([]byte, 32)
+	pubDER, _ := newFakeValidatorKey(t, digest)
+
+	backend := &fakeKMS{publicKeyDER: pubDER, signErr: errors.New("backend unavailable")}
+
+	mgr := NewSecretsManager(ManagerConfig{
+		Type:    secrets.AwsKms,
+		KeyID:   "test-key",
+		Backend: backend,
+		Logger:  hclog.NewNullLogger(),
+		LocalSM: newFakeLocalSM(),
+	})
+
+	if _, err := mgr.SignBySecret(secrets.ValidatorKey, digest); err == nil {
+		t.Fatal("expected SignBySecret to propagate the backend's error")
+	}
+}
+
+func TestSecretsManagerNetworkKeyWrapsAndUnwraps(t *testing.T) {
+	localSM := newFakeLocalSM()
+
+	wrapped := false
+
+	mgr := NewSecretsManager(ManagerConfig{
+		Type:    secrets.AwsKms,
+		LocalSM: localSM,
+		WrapSecret: func(v []byte) ([]byte, error) {
+			wrapped = true
+
+			return append([]byte("wrapped:"), v...), nil
+		},
+		UnwrapSecret: func(v []byte) ([]byte, error) {
+			return bytes.TrimPrefix(v, []byte("wrapped:")), nil
+		},
+	})
+
+	if err := mgr.SetSecret(secrets.NetworkKey, []byte("network-key-bytes")); err != nil {
+		t.Fatalf("SetSecret returned error: %v", err)
+	}
+
+	if !wrapped {
+		t.Fatal("expected SetSecret to call WrapSecret for the network key")
+	}
+
+	raw, ok := localSM.secrets[secrets.NetworkKey]
+	if !ok || !bytes.HasPrefix(raw, []byte("wrapped:")) {
+		t.Fatalf("expected localSM to store the wrapped value, got %q", raw)
+	}
+
+	got, err := mgr.GetSecret(secrets.NetworkKey)
+	if err != nil {
+		t.Fatalf("GetSecret returned error: %v", err)
+	}
+
+	if string(got) != "network-key-bytes" {
+		t.Fatalf("got %q want %q", got, "network-key-bytes")
+	}
+}
+
+func TestSecretsManagerNetworkKeyPassthroughWithoutHooks(t *testing.T) {
+	localSM := newFakeLocalSM()
+
+	mgr := NewSecretsManager(ManagerConfig{
+		Type:    secrets.AwsKms,
+		LocalSM: localSM,
+	})
+
+	if err := mgr.SetSecret(secrets.NetworkKey, []byte("plain")); err != nil {
+		t.Fatalf("SetSecret returned error: %v", err)
+	}
+
+	got, err := mgr.GetSecret(secrets.NetworkKey)
+	if err != nil {
+		t.Fatalf("GetSecret returned error: %v", err)
+	}
+
+	if string(got) != "plain" {
+		t.Fatalf("got %q want %q", got, "plain")
+	}
+}
+
+func TestSecretsManagerGetSecretsManagerType(t *testing.T) {
+	mgr := NewSecretsManager(ManagerConfig{Type: secrets.Pkcs11})
+
+	if mgr.GetSecretsManagerType() != secrets.Pkcs11 {
+		t.Fatalf("got %q want %q", mgr.GetSecretsManagerType(), secrets.Pkcs11)
+	}
+}
@@ -0,0 +1,117 @@
+// Package pkcs11 implements the kms.KMS interface on top of a PKCS#11 HSM
+// via github.com/ThalesIgnite/crypto11
+package pkcs11
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"github.com/ThalesIgnite/crypto11"
+
+	secp256k1 "github.com/0xPolygon/polygon-edge/crypto"
+	kmsapi "github.com/0xPolygon/polygon-edge/secrets/kms"
+)
+
+func init() {
+	kmsapi.Register(kmsapi.PKCS11, New)
+}
+
+// KMS implements kmsapi.KMS on top of a PKCS#11 HSM session
+type KMS struct {
+	ctx *crypto11.Context
+}
+
+// New opens a session against the PKCS#11 module described by opts
+func New(_ context.Context, opts kmsapi.Options) (kmsapi.KMS, error) {
+	if opts.ModulePath == "" {
+		return nil, errors.New("pkcs11: no module path specified")
+	}
+
+	ctx, err := crypto11.Configure(&crypto11.Config{
+		Path:       opts.ModulePath,
+		TokenLabel: opts.TokenLabel,
+		Pin:        opts.Pin,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("pkcs11: unable to open session: %w", err)
+	}
+
+	return &KMS{ctx: ctx}, nil
+}
+
+// GetPublicKey implements kmsapi.KMS. req.KeyID is the PKCS#11 object label
+func (k *KMS) GetPublicKey(_ context.Context, req *kmsapi.GetPublicKeyRequest) (*kmsapi.GetPublicKeyResponse, error) {
+	signer, err := k.findSigner(req.KeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	// x509.MarshalPKIXPublicKey only knows the NIST curves, so a
+	// secp256k1 key has to be marshaled by hand
+	der, err := kmsapi.MarshalSecp256k1PublicKeyToDER(signer.Public().(*ecdsa.PublicKey))
+	if err != nil {
+		return nil, fmt.Errorf("pkcs11: unable to marshal public key: %w", err)
+	}
+
+	return &kmsapi.GetPublicKeyResponse{PublicKeyDER: der}, nil
+}
+
+// SignDigest implements kmsapi.KMS
+func (k *KMS) SignDigest(_ context.Context, req *kmsapi.SignDigestRequest) (*kmsapi.SignDigestResponse, error) {
+	signer, err := k.findSigner(req.KeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := signer.Sign(rand.Reader, req.Digest, crypto.SHA256)
+	if err != nil {
+		return nil, fmt.Errorf("pkcs11: sign error: %w", err)
+	}
+
+	return &kmsapi.SignDigestResponse{SignatureDER: der}, nil
+}
+
+// CreateKey implements kmsapi.KMS, generating a new secp256k1 ECDSA key
+// pair on the HSM under the label req.Name
+func (k *KMS) CreateKey(_ context.Context, req *kmsapi.CreateKeyRequest) (*kmsapi.CreateKeyResponse, error) {
+	id := []byte(req.Name)
+
+	key, err := k.ctx.GenerateECDSAKeyPairWithLabel(id, id, secp256k1.S256())
+	if err != nil {
+		return nil, fmt.Errorf("pkcs11: unable to generate key: %w", err)
+	}
+
+	der, err := kmsapi.MarshalSecp256k1PublicKeyToDER(key.Public().(*ecdsa.PublicKey))
+	if err != nil {
+		return nil, fmt.Errorf("pkcs11: unable to marshal public key: %w", err)
+	}
+
+	return &kmsapi.CreateKeyResponse{KeyID: req.Name, PublicKeyDER: der}, nil
+}
+
+// Close implements kmsapi.KMS
+func (k *KMS) Close() error {
+	return k.ctx.Close()
+}
+
+// findSigner looks up the ECDSA key pair registered under the given label
+func (k *KMS) findSigner(label string) (crypto11.Signer, error) {
+	signer, err := k.ctx.FindKeyPair(nil, []byte(label))
+	if err != nil {
+		return nil, fmt.Errorf("pkcs11: unable to find key %q: %w", label, err)
+	}
+
+	if signer == nil {
+		return nil, fmt.Errorf("pkcs11: key %q not found", label)
+	}
+
+	if _, ok := signer.Public().(*ecdsa.PublicKey); !ok {
+		return nil, fmt.Errorf("pkcs11: key %q is not an ECDSA key", label)
+	}
+
+	return signer, nil
+}
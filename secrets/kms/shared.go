@@ -0,0 +1,166 @@
+package kms
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/0xPolygon/polygon-edge/crypto"
+	"github.com/0xPolygon/polygon-edge/secrets"
+	"github.com/0xPolygon/polygon-edge/secrets/local"
+	"github.com/hashicorp/go-hclog"
+)
+
+// oidPublicKeyECDSA and oidNamedCurveSecp256k1 are the SubjectPublicKeyInfo
+// OIDs for an EC public key on the secp256k1 curve. crypto/x509 only knows
+// the NIST P-224/256/384/521 curves (see oidFromNamedCurve in its source),
+// so it refuses to marshal a secp256k1 key; backends that hand back a raw
+// (X, Y) point instead of an already-DER-encoded key (pkcs11) need to build
+// the SubjectPublicKeyInfo themselves using these OIDs
+var (
+	oidPublicKeyECDSA      = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
+	oidNamedCurveSecp256k1 = asn1.ObjectIdentifier{1, 3, 132, 0, 10}
+)
+
+// secp256k1HalfN is half of the secp256k1 curve order, used to normalize
+// the S value of a signature per EIP-2, since none of the backends
+// guarantee a low-S signature on their own
+var secp256k1HalfN = new(big.Int).Rsh(crypto.S256().Params().N, 1)
+
+// ecdsaSigValue is the ASN.1 DER structure every backend returns from a
+// Sign/SignDigest call
+type ecdsaSigValue struct {
+	R *big.Int
+	S *big.Int
+}
+
+// subjectPublicKeyInfo is the ASN.1 DER structure every backend returns
+// from GetPublicKey
+type subjectPublicKeyInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	PublicKey asn1.BitString
+}
+
+// PublicKeyDERToSecretInfo parses a DER-encoded SubjectPublicKeyInfo into
+// the secp256k1 point and derives the corresponding Ethereum address, so
+// every backend-specific SecretsManager can share the same conversion code
+func PublicKeyDERToSecretInfo(der []byte) (*secrets.SecretInfo, error) {
+	var spki subjectPublicKeyInfo
+	if _, err := asn1.Unmarshal(der, &spki); err != nil {
+		return nil, fmt.Errorf("unable to parse kms public key: %w", err)
+	}
+
+	x, y := elliptic.Unmarshal(crypto.S256(), spki.PublicKey.Bytes)
+	if x == nil {
+		return nil, errors.New("kms public key is not a valid secp256k1 point")
+	}
+
+	pubKeyBytes := elliptic.Marshal(crypto.S256(), x, y)
+	address := crypto.PubKeyToAddress(pubKeyBytes)
+
+	return &secrets.SecretInfo{
+		Pubkey:  fmt.Sprintf("0x%x", pubKeyBytes),
+		Address: address.String(),
+	}, nil
+}
+
+// MarshalSecp256k1PublicKeyToDER builds the ASN.1 DER SubjectPublicKeyInfo
+// for a secp256k1 EC public key by hand, for backends whose SDK returns a
+// *ecdsa.PublicKey rather than an already-DER-encoded key (x509.MarshalPKIXPublicKey
+// cannot do this itself, see the oidPublicKeyECDSA doc comment above)
+func MarshalSecp256k1PublicKeyToDER(pub *ecdsa.PublicKey) ([]byte, error) {
+	if pub == nil || pub.Curve != crypto.S256() {
+		return nil, errors.New("kms: public key is not a secp256k1 key")
+	}
+
+	curveOID, err := asn1.Marshal(oidNamedCurveSecp256k1)
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal secp256k1 curve oid: %w", err)
+	}
+
+	point := elliptic.Marshal(pub.Curve, pub.X, pub.Y)
+
+	der, err := asn1.Marshal(subjectPublicKeyInfo{
+		Algorithm: pkix.AlgorithmIdentifier{
+			Algorithm:  oidPublicKeyECDSA,
+			Parameters: asn1.RawValue{FullBytes: curveOID},
+		},
+		PublicKey: asn1.BitString{Bytes: point, BitLength: len(point) * 8},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal public key: %w", err)
+	}
+
+	return der, nil
+}
+
+// SignatureDERToEthereum parses a DER-encoded ECDSA-Sig-Value, normalizes S
+// to the lower half of the secp256k1 order (EIP-2) and recovers the
+// recovery id by trying both 0 and 1 against the expected address,
+// returning the 65-byte Ethereum-style signature (R || S || V)
+func SignatureDERToEthereum(der []byte, digest []byte, expectedAddress string) ([]byte, error) {
+	var sig ecdsaSigValue
+	if _, err := asn1.Unmarshal(der, &sig); err != nil {
+		return nil, fmt.Errorf("unable to parse kms signature: %w", err)
+	}
+
+	if sig.S.Cmp(secp256k1HalfN) > 0 {
+		sig.S = new(big.Int).Sub(crypto.S256().Params().N, sig.S)
+	}
+
+	for v := byte(0); v < 2; v++ {
+		candidate, err := crypto.EncodeSignature(sig.R, sig.S, v)
+		if err != nil {
+			continue
+		}
+
+		recoveredPub, err := crypto.Ecrecover(digest, candidate)
+		if err != nil {
+			continue
+		}
+
+		if crypto.PubKeyToAddress(recoveredPub).String() == expectedAddress {
+			return candidate, nil
+		}
+	}
+
+	return nil, errors.New("unable to recover recovery id for kms signature")
+}
+
+// NewNetworkKeyFallback returns a local SecretsManager used by every
+// KMS-backed SecretsManager to store the libp2p network key, which has no
+// equivalent concept in a KMS/HSM
+func NewNetworkKeyFallback(params *secrets.SecretsManagerParams) (secrets.SecretsManager, error) {
+	return local.SecretsManagerFactory(
+		nil, // Local secrets manager doesn't require a config
+		params,
+	)
+}
+
+// AuditSign writes a structured audit record for a single signing call:
+// the key used, a hash of the payload (never the raw payload) and the
+// remote call latency. The record is a plain log line; it carries no
+// chaining or signature of its own. Every KMS/HSM-backed SecretsManager calls this
+// from SignBySecret so operators get a consistent signing trail regardless
+// of backend
+func AuditSign(logger hclog.Logger, backend, keyID string, payload []byte, start time.Time, err error) {
+	fields := []interface{}{
+		"backend", backend,
+		"key_id", keyID,
+		"payload_hash", crypto.Keccak256Hash(payload).String(),
+		"remote_latency", time.Since(start).String(),
+	}
+
+	if err != nil {
+		logger.Error("validator signing audit: sign failed", append(fields, "error", err)...)
+
+		return
+	}
+
+	logger.Info("validator signing audit", fields...)
+}
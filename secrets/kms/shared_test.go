@@ -0,0 +1,124 @@
+package kms
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/asn1"
+	"testing"
+
+	"github.com/0xPolygon/polygon-edge/crypto"
+)
+
+func generateTestKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+
+	priv, err := ecdsa.GenerateKey(crypto.S256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unable to generate secp256k1 key: %v", err)
+	}
+
+	return priv
+}
+
+func TestPublicKeyDERToSecretInfo(t *testing.T) {
+	priv := generateTestKey(t)
+
+	der, err := MarshalSecp256k1PublicKeyToDER(&priv.PublicKey)
+	if err != nil {
+		t.Fatalf("MarshalSecp256k1PublicKeyToDER returned error: %v", err)
+	}
+
+	info, err := PublicKeyDERToSecretInfo(der)
+	if err != nil {
+		t.Fatalf("PublicKeyDERToSecretInfo returned error: %v", err)
+	}
+
+	wantAddress := crypto.PubKeyToAddress(
+		elliptic.Marshal(crypto.S256(), priv.PublicKey.X, priv.PublicKey.Y),
+	).String()
+
+	if info.Address != wantAddress {
+		t.Fatalf("address mismatch: got %s want %s", info.Address, wantAddress)
+	}
+}
+
+func TestPublicKeyDERToSecretInfoRejectsGarbage(t *testing.T) {
+	if _, err := PublicKeyDERToSecretInfo([]byte("not a der blob")); err == nil {
+		t.Fatal("expected an error for malformed DER input")
+	}
+}
+
+func TestSignatureDERToEthereum(t *testing.T) {
+	priv := generateTestKey(t)
+
+	address := crypto.PubKeyToAddress(
+		elliptic.Marshal(crypto.S256(), priv.PublicKey.X, priv.PublicKey.Y),
+	).String()
+
+	digest := make([]byte, 32)
+	if _, err := rand.Read(digest); err != nil {
+		t.Fatalf("unable to generate digest: %v", err)
+	}
+
+	r, s, err := ecdsa.Sign(rand.Reader, priv, digest)
+	if err != nil {
+		t.Fatalf("unable to sign digest: %v", err)
+	}
+
+	der, err := asn1.Marshal(ecdsaSigValue{R: r, S: s})
+	if err != nil {
+		t.Fatalf("unable to marshal ecdsa signature: %v", err)
+	}
+
+	sig, err := SignatureDERToEthereum(der, digest, address)
+	if err != nil {
+		t.Fatalf("SignatureDERToEthereum returned error: %v", err)
+	}
+
+	if len(sig) != 65 {
+		t.Fatalf("expected a 65-byte signature, got %d bytes", len(sig))
+	}
+
+	recoveredPub, err := crypto.Ecrecover(digest, sig)
+	if err != nil {
+		t.Fatalf("unable to recover public key from signature: %v", err)
+	}
+
+	if crypto.PubKeyToAddress(recoveredPub).String() != address {
+		t.Fatal("recovered address does not match the signing key's address")
+	}
+
+	// EIP-2: S must always end up in the lower half of the curve order
+	if sig[32]&0x80 != 0 {
+		t.Fatal("signature S value was not normalized to the low half of the curve order")
+	}
+}
+
+func TestSignatureDERToEthereumRejectsMismatchedAddress(t *testing.T) {
+	priv := generateTestKey(t)
+	other := generateTestKey(t)
+
+	otherAddress := crypto.PubKeyToAddress(
+		elliptic.Marshal(crypto.S256(), other.PublicKey.X, other.PublicKey.Y),
+	).String()
+
+	digest := make([]byte, 32)
+	if _, err := rand.Read(digest); err != nil {
+		t.Fatalf("unable to generate digest: %v", err)
+	}
+
+	r, s, err := ecdsa.Sign(rand.Reader, priv, digest)
+	if err != nil {
+		t.Fatalf("unable to sign digest: %v", err)
+	}
+
+	der, err := asn1.Marshal(ecdsaSigValue{R: r, S: s})
+	if err != nil {
+		t.Fatalf("unable to marshal ecdsa signature: %v", err)
+	}
+
+	if _, err := SignatureDERToEthereum(der, digest, otherAddress); err == nil {
+		t.Fatal("expected an error when the signature does not recover to expectedAddress")
+	}
+}
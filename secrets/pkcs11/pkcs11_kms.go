@@ -0,0 +1,59 @@
+// Package pkcs11 wires the pkcs11 KMS backend into the SecretsManager
+// interface, the same way secrets/awskms does for AWS KMS
+package pkcs11
+
+import (
+	"context"
+	"errors"
+
+	"github.com/0xPolygon/polygon-edge/secrets"
+	kmsapi "github.com/0xPolygon/polygon-edge/secrets/kms"
+	_ "github.com/0xPolygon/polygon-edge/secrets/kms/pkcs11" // registers kmsapi.PKCS11
+)
+
+// SecretsManagerFactory implements the factory method
+func SecretsManagerFactory(
+	config *secrets.SecretsManagerConfig,
+	params *secrets.SecretsManagerParams,
+) (secrets.SecretsManager, error) {
+	if config.Name == "" {
+		return nil, errors.New("no node name specified for kms secrets manager")
+	}
+
+	keyID, ok := config.Extra["kms-key-id"].(string)
+	if !ok || keyID == "" {
+		return nil, errors.New("no kms key id specified for kms secrets manager")
+	}
+
+	modulePath, ok := config.Extra["module-path"].(string)
+	if !ok || modulePath == "" {
+		return nil, errors.New("no pkcs11 module path specified for kms secrets manager")
+	}
+
+	tokenLabel, _ := config.Extra["token-label"].(string)
+	pin, _ := config.Extra["pin"].(string)
+
+	backend, err := kmsapi.New(context.Background(), kmsapi.Options{
+		Type:       kmsapi.PKCS11,
+		ModulePath: modulePath,
+		TokenLabel: tokenLabel,
+		Pin:        pin,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	localSM, err := kmsapi.NewNetworkKeyFallback(params)
+	if err != nil {
+		return nil, err
+	}
+
+	return kmsapi.NewSecretsManager(kmsapi.ManagerConfig{
+		Type:      secrets.Pkcs11,
+		ErrPrefix: "pkcs11",
+		KeyID:     keyID,
+		Backend:   backend,
+		Logger:    params.Logger.Named(string(secrets.Pkcs11)),
+		LocalSM:   localSM,
+	}), nil
+}